@@ -0,0 +1,98 @@
+package sproket
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Backoff controls how long to wait between retry attempts against a flaky
+// ESGF index or data node.
+type Backoff interface {
+	// Next returns how long to wait before the given retry attempt (the
+	// first retry is attempt 1).
+	Next(retry int) time.Duration
+	// Reset clears any state accumulated across a run of retries so the
+	// next Next(1) call starts from the initial delay again.
+	Reset()
+}
+
+// ExponentialBackoff multiplies its wait by Multiplier (2 if unset) on every
+// attempt, capped at Max, and
+// optionally adds uniform jitter in [0, current) so that many clients
+// retrying against the same data node don't all retry in lockstep.
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     bool
+}
+
+// Next implements Backoff. Since the attempt number is supplied by the
+// caller rather than tracked internally, Next is stateless and safe to call
+// from multiple goroutines sharing the same ExponentialBackoff.
+func (e *ExponentialBackoff) Next(retry int) time.Duration {
+	initial := e.Initial
+	if initial <= 0 {
+		initial = time.Second
+	}
+	multiplier := e.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	wait := float64(initial)
+	for i := 1; i < retry; i++ {
+		wait *= multiplier
+	}
+	d := time.Duration(wait)
+	if e.Max > 0 && d > e.Max {
+		d = e.Max
+	}
+	if e.Jitter && d > 0 {
+		d += time.Duration(rand.Int63n(int64(d)))
+	}
+	return d
+}
+
+// Reset is a no-op for ExponentialBackoff: it carries no state between
+// attempts, the retry count is passed into Next by the caller instead.
+func (e *ExponentialBackoff) Reset() {}
+
+// defaultBackoff and defaultMaxRetries are used whenever a Search does not
+// configure its own Backoff/MaxRetries.
+var defaultBackoff Backoff = &ExponentialBackoff{
+	Initial:    500 * time.Millisecond,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	Jitter:     true,
+}
+
+const defaultMaxRetries = 5
+
+func (s *Search) backoff() Backoff {
+	if s.Backoff != nil {
+		return s.Backoff
+	}
+	return defaultBackoff
+}
+
+func (s *Search) maxRetries() int {
+	if s.MaxRetries > 0 {
+		return s.MaxRetries
+	}
+	return defaultMaxRetries
+}
+
+// isRetryableStatus reports whether an HTTP status code represents a
+// transient failure worth retrying against a Solr index or data node.
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusRequestTimeout,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,41 @@
+package sproket
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// Hasher returns a hash.Hash for the checksum_type ESGF advertises on a Doc
+// (sumType, as returned by Doc.GetSumType).
+func Hasher(sumType string) (hash.Hash, error) {
+	switch sumType {
+	case "MD5":
+		return md5.New(), nil
+	case "SHA256":
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unrecognized checksum_type: %s", sumType)
+	}
+}
+
+// VerifyFile hashes the file at path with the algorithm named by sumType and
+// reports whether the result matches want.
+func VerifyFile(path string, want string, sumType string) (bool, error) {
+	h, err := Hasher(sumType)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)) == want, nil
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"hash"
@@ -11,11 +13,16 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"sproket"
+	"sproket/index"
+	"sproket/web"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
 // VERSION is the current version of sproket
@@ -40,6 +47,12 @@ type config struct {
 	displayDataNodes bool
 	softDataNode     bool
 	unsafe           bool
+	progress         bool
+	silent           bool
+	indexBuild       string
+	indexUse         string
+	serve            string
+	output           string
 	search           sproket.Search
 }
 
@@ -76,6 +89,26 @@ func (args *config) Init() error {
 	args.search.Agent = AGENT
 	args.search.HTTPClient = &http.Client{}
 
+	// Configure the retry backoff from the JSON config, falling back to
+	// sproket's defaults for any value left unset
+	initial := time.Duration(args.search.RetryInitialMs) * time.Millisecond
+	max := time.Duration(args.search.RetryMaxMs) * time.Millisecond
+	if initial > 0 || max > 0 {
+		if initial <= 0 {
+			initial = 500 * time.Millisecond
+		}
+		if max <= 0 {
+			max = 30 * time.Second
+		}
+		args.search.Backoff = &sproket.ExponentialBackoff{
+			Initial:    initial,
+			Max:        max,
+			Multiplier: 2,
+			Jitter:     true,
+		}
+	}
+	args.search.MaxRetries = args.search.RetryMaxAttempts
+
 	if _, err := os.Stat(args.outDir); os.IsNotExist(err) {
 		return fmt.Errorf("directory %s does not exist", args.outDir)
 	}
@@ -116,9 +149,15 @@ func check(dest string, remoteSum string, remoteSumType string) error {
 	return nil
 }
 
-func getData(id int, inDocs <-chan sproket.Doc, waiter *sync.WaitGroup, args *config) {
+func getData(ctx context.Context, id int, inDocs <-chan sproket.Doc, waiter *sync.WaitGroup, args *config, bar *progressBar) {
 	defer waiter.Done()
 	for doc := range inDocs {
+		// Stop picking up new downloads once cancellation has been requested;
+		// any in-progress .part file is left behind so a re-run can resume it.
+		if ctx.Err() != nil {
+			return
+		}
+
 		// Report download when verbose
 		if args.verbose {
 			fmt.Printf("%d: download %s\n", id, doc.HTTPURL)
@@ -148,20 +187,37 @@ func getData(id int, inDocs <-chan sproket.Doc, waiter *sync.WaitGroup, args *co
 				}
 			}
 
-			// Create the destination file
-			fileWriter, err := os.Create(destName)
+			// Create hash for potential later use
+			h, hashErr := getHasher(finalDestName, doc.GetSum(), doc.GetSumType())
+
+			// Resume from an existing .part file if one is present, seeding
+			// the running checksum with the bytes it already holds so
+			// verification still succeeds when a download spans process
+			// restarts.
+			var resumeFrom int64
+			if info, err := os.Stat(destName); err == nil && info.Size() > 0 {
+				if hashErr == nil && !(args.noVerify) {
+					if seedErr := seedHash(h, destName); seedErr != nil {
+						fmt.Printf("%d: unable to resume %s, restarting: %s\n", id, destName, seedErr)
+						h.Reset()
+					} else {
+						resumeFrom = info.Size()
+					}
+				} else {
+					resumeFrom = info.Size()
+				}
+			}
+
+			fileWriter, err := openPart(destName, resumeFrom)
 			if err != nil {
-				fmt.Printf("%d: unable to create %s: %s\n", id, destName, err)
+				fmt.Printf("%d: unable to open %s: %s\n", id, destName, err)
 				continue
 			}
-			defer fileWriter.Close()
 
 			// Create destination writer and set the default writer
 			var dest io.Writer
 			dest = fileWriter
 
-			// Create hash for potential later use
-			h, hashErr := getHasher(finalDestName, doc.GetSum(), doc.GetSumType())
 			if hashErr != nil && !(args.noVerify) {
 				fmt.Printf("%d: hash warning: %s\n", id, hashErr)
 			} else if !(args.noVerify) {
@@ -169,10 +225,42 @@ func getData(id int, inDocs <-chan sproket.Doc, waiter *sync.WaitGroup, args *co
 				dest = io.MultiWriter(h, fileWriter)
 			}
 
-			// Perform download
-			err = args.search.Get(doc.HTTPURL, dest)
+			// validatorName persists the ETag/Last-Modified in effect when
+			// destName was (re)started, so a resume across process restarts
+			// checks the partial file against the same remote version it was
+			// written from, not whatever the remote happens to be serving at
+			// resume time. It is written as soon as the headers for a fresh
+			// attempt arrive, not after the transfer completes, so it still
+			// survives an interrupt or exhausted retries mid-download.
+			validatorName := destName + ".etag"
+			validator := readValidator(validatorName, resumeFrom)
+			onValidator := func(v string) { writeValidator(validatorName, v) }
+
+			// Perform download, restarting from scratch if the server could
+			// not honor the resume request (it changed, or doesn't support
+			// ranges)
+			err = args.search.Get(ctx, doc.HTTPURL, dest, resumeFrom, bar.Add, validator, onValidator)
+			if errors.Is(err, sproket.ErrRestartRequired) {
+				fileWriter.Close()
+				if hashErr == nil {
+					h.Reset()
+				}
+				if fileWriter, err = openPart(destName, 0); err != nil {
+					fmt.Printf("%d: unable to restart %s: %s\n", id, destName, err)
+					continue
+				}
+				dest = fileWriter
+				if hashErr == nil && !(args.noVerify) {
+					dest = io.MultiWriter(h, fileWriter)
+				}
+				err = args.search.Get(ctx, doc.HTTPURL, dest, 0, bar.Add, "", onValidator)
+			}
 			fileWriter.Close()
 			if err != nil {
+				if ctx.Err() != nil {
+					// Cancelled mid-transfer; leave the .part file for a later resume
+					return
+				}
 				fmt.Printf("%d: an error occurred during download of %s:\n\t%s\n", id, doc.HTTPURL, err)
 				continue
 			}
@@ -194,7 +282,9 @@ func getData(id int, inDocs <-chan sproket.Doc, waiter *sync.WaitGroup, args *co
 				if err != nil {
 					fmt.Println(err)
 					continue
-				} else if args.verbose {
+				}
+				os.Remove(validatorName)
+				if args.verbose {
 					fmt.Printf("%d: removed postfix %s\n", id, finalDestName)
 				}
 			}
@@ -202,26 +292,104 @@ func getData(id int, inDocs <-chan sproket.Doc, waiter *sync.WaitGroup, args *co
 	}
 }
 
-func getBySearch(args *config) {
+// openPart opens a .part file for writing, truncating it first unless
+// resumeFrom is non-zero, in which case bytes are appended after the
+// existing content.
+func openPart(destName string, resumeFrom int64) (*os.File, error) {
+	if resumeFrom > 0 {
+		return os.OpenFile(destName, os.O_WRONLY|os.O_APPEND, 0644)
+	}
+	return os.Create(destName)
+}
 
-	// Count original files, only files with "replica: false" entries present in the index will be downloaded
-	args.search.Fields["replica"] = "false"
-	if args.verbose {
-		fmt.Println(args.search)
+// readValidator returns the If-Range validator persisted alongside a .part
+// file the last time it was (re)started, or "" if resumeFrom is zero (a
+// fresh download has nothing to validate against yet) or none was persisted.
+func readValidator(validatorName string, resumeFrom int64) string {
+	if resumeFrom == 0 {
+		return ""
 	}
-	_, n := args.search.SearchURLs(0, 0)
-	if !(args.urlsOnly) {
-		fmt.Printf("found %d files for download\n", n)
+	b, err := ioutil.ReadFile(validatorName)
+	if err != nil {
+		return ""
 	}
-	if args.count || n == 0 {
+	return string(b)
+}
+
+// writeValidator persists validator alongside a .part file so a later resume
+// can validate against the remote version in effect when the download was
+// (re)started, rather than whatever the remote is serving at resume time. A
+// blank validator is a no-op: the remote gave us nothing to validate against.
+func writeValidator(validatorName string, validator string) {
+	if validator == "" {
 		return
 	}
-	warnCount := 100
-	if !(args.confirm) && n > warnCount {
-		fmt.Printf("too many files (%d > %d): confirm larger download by specifying the -y option or refine search criteria\n", n, warnCount)
+	ioutil.WriteFile(validatorName, []byte(validator), 0644)
+}
+
+// seedHash feeds the existing contents of destName into h so that a checksum
+// started before a resumed download still verifies against the full file.
+func seedHash(h hash.Hash, destName string) error {
+	f, err := os.Open(destName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+// submit sends doc to docChan, returning false instead of blocking forever if
+// ctx is cancelled before a worker is free to receive it.
+func submit(ctx context.Context, docChan chan<- sproket.Doc, doc sproket.Doc) bool {
+	select {
+	case docChan <- doc:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// streamSearch drives resolveDocs itself, writing one record per matching
+// file straight to stdout via a sproket.DocEncoder as each one is resolved,
+// rather than buffering the whole result set or downloading it. Like the
+// download path, it runs data_node_priority resolution first, so a jsonl or
+// stac record reflects the data node actually chosen for that file, not just
+// whichever one the bare search happened to return first. When that
+// resolution is active, resolveDocs collects every matching file's candidates
+// in memory before the first record is written.
+func streamSearch(ctx context.Context, args *config) {
+	var enc sproket.DocEncoder
+	switch args.output {
+	case "urls":
+		enc = &sproket.URLEncoder{W: os.Stdout}
+	case "jsonl":
+		enc = sproket.NewJSONLEncoder(os.Stdout)
+	case "stac":
+		enc = sproket.NewSTACEncoder(os.Stdout, args.search.Fields)
+	default:
+		fmt.Printf("unrecognized -output value %q, expected urls, jsonl, or stac\n", args.output)
 		return
 	}
 
+	resolveDocs(ctx, args, func(doc sproket.Doc) bool {
+		if err := enc.Encode(doc); err != nil {
+			fmt.Println(err)
+			return false
+		}
+		return true
+	})
+}
+
+// resolveDocs pages through args.search's matching files and, when
+// args.softDataNode is set, resolves each instance_id to its preferred data
+// node the same way getBySearch's download path always has: only a replica
+// whose data node appears earliest in args.search.DataNodePriority is chosen
+// over the original when one matches, falling back to the original
+// otherwise. It calls emit once per resolved Doc, in no particular order;
+// emit returning false (e.g. because ctx was cancelled, or a caller's output
+// write failed) stops resolution early.
+func resolveDocs(ctx context.Context, args *config, emit func(doc sproket.Doc) bool) {
 	// Check if the soft data node list will even matter
 	dataNodeMatches := make(map[string]bool)
 	if args.softDataNode {
@@ -246,28 +414,23 @@ func getBySearch(args *config) {
 		args.search.Fields["replica"] = "false"
 	}
 
-	// Setup download workers in case data node does not matter and for later
-	docChan := make(chan sproket.Doc)
-	waiter := sync.WaitGroup{}
-	for id := 0; id < args.parallel; id++ {
-		waiter.Add(1)
-		go getData(id, docChan, &waiter, args)
-	}
-
 	// Get documents that are all originals and assurred to be the true latest files
 	allDocs := make(map[string]map[string]sproket.Doc)
 	limit := 250
+paging:
 	for cur := 0; ; cur += limit {
 		docs, remaining := args.search.SearchURLs(cur, limit)
 		for _, doc := range docs {
 			if !(args.softDataNode) {
-				docChan <- doc
+				if !emit(doc) {
+					break paging
+				}
 			} else {
 				allDocs[doc.InstanceID] = make(map[string]sproket.Doc)
 				allDocs[doc.InstanceID][doc.DataNode] = doc
 			}
 		}
-		if remaining == 0 {
+		if remaining == 0 || ctx.Err() != nil {
 			break
 		}
 	}
@@ -303,12 +466,18 @@ func getBySearch(args *config) {
 
 		jobsSubmitted := 0
 		prefJobsSubmitted := 0
+	replicas:
 		for _, dataNodeMap := range allDocs {
+			if ctx.Err() != nil {
+				break
+			}
 			foundPreffered := false
 			for _, prefferedDataNode := range args.search.DataNodePriority {
 				for dataNode, doc := range dataNodeMap {
 					if prefferedDataNode == dataNode {
-						docChan <- doc
+						if !emit(doc) {
+							break replicas
+						}
 						foundPreffered = true
 						jobsSubmitted++
 						prefJobsSubmitted++
@@ -321,7 +490,9 @@ func getBySearch(args *config) {
 			}
 			if !(foundPreffered) {
 				for _, doc := range dataNodeMap {
-					docChan <- doc
+					if !emit(doc) {
+						break replicas
+					}
 					jobsSubmitted++
 					break
 				}
@@ -332,6 +503,53 @@ func getBySearch(args *config) {
 			fmt.Printf("%d preferred downloads submitted\n", prefJobsSubmitted)
 		}
 	}
+}
+
+func getBySearch(ctx context.Context, args *config) {
+
+	// Count original files, only files with "replica: false" entries present in the index will be downloaded
+	args.search.Fields["replica"] = "false"
+	if args.verbose {
+		fmt.Println(args.search)
+	}
+	_, n := args.search.SearchURLs(0, 0)
+	if !(args.urlsOnly) && args.output == "" {
+		fmt.Printf("found %d files for download\n", n)
+	}
+	if args.count || n == 0 {
+		return
+	}
+	if args.output != "" {
+		streamSearch(ctx, args)
+		return
+	}
+
+	warnCount := 100
+	if !(args.confirm) && n > warnCount {
+		fmt.Printf("too many files (%d > %d): confirm larger download by specifying the -y option or refine search criteria\n", n, warnCount)
+		return
+	}
+
+	// Set up an aggregate progress display, if requested, summing the bytes
+	// reported by every worker on a ticker
+	var bar *progressBar
+	if args.progress {
+		bar = newProgressBar(0, 500*time.Millisecond)
+		defer bar.Stop()
+	}
+
+	// Setup download workers in case data node does not matter and for later
+	docChan := make(chan sproket.Doc)
+	waiter := sync.WaitGroup{}
+	for id := 0; id < args.parallel; id++ {
+		waiter.Add(1)
+		go getData(ctx, id, docChan, &waiter, args, bar)
+	}
+
+	resolveDocs(ctx, args, func(doc sproket.Doc) bool {
+		return submit(ctx, docChan, doc)
+	})
+
 	close(docChan)
 	waiter.Wait()
 }
@@ -465,6 +683,12 @@ func main() {
 	flag.BoolVar(&args.version, "version", false, "Flag to output the version and exit")
 	flag.BoolVar(&args.urlsOnly, "urls.only", false, "Flag to only output to stdout the HTTP URLs that would be used")
 	flag.BoolVar(&args.unsafe, "unsafe", false, "Removes the hard set requirement of the retracted field being false and latest being true. The user is then free to specify these fields themselves in the search config, but are not required to.")
+	flag.BoolVar(&args.progress, "progress", false, "Flag to display an aggregate progress bar summing the bytes downloaded by all workers")
+	flag.BoolVar(&args.silent, "silent", false, "Flag to suppress the progress bar even when -progress is set, useful when piping output")
+	flag.StringVar(&args.indexBuild, "index.build", "", "Path to snapshot the current search's results to, for offline reuse with -index.use")
+	flag.StringVar(&args.indexUse, "index.use", "", "Path to a snapshot written by -index.build; search and download are served from it instead of the live index")
+	flag.StringVar(&args.serve, "serve", "", "Address (e.g. :8080) to serve an interactive search, facet browsing and download UI on, instead of running a one-shot search or download")
+	flag.StringVar(&args.output, "output", "", "Stream one record per matching file to stdout as the search pages through results, instead of downloading: 'urls', 'jsonl', or 'stac'")
 	flag.Parse()
 	if args.version {
 		fmt.Println(VERSION)
@@ -480,15 +704,65 @@ func main() {
 		fmt.Println(err)
 		return
 	}
-	if args.displayDataNodes {
+	if args.silent {
+		args.progress = false
+	}
+
+	if args.indexBuild != "" {
+		if err := index.Build(&args.search, args.indexBuild); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("wrote index snapshot to %s\n", args.indexBuild)
+		return
+	}
+
+	if args.indexUse != "" {
+		snapshotSearch, err := index.Open(args.indexUse)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		// Carry over connection settings and any criteria the user refined
+		// this run; only the source of documents changes
+		snapshotSearch.Agent = args.search.Agent
+		snapshotSearch.HTTPClient = args.search.HTTPClient
+		snapshotSearch.Backoff = args.search.Backoff
+		snapshotSearch.MaxRetries = args.search.MaxRetries
+		snapshotSearch.DataNodePriority = args.search.DataNodePriority
+		for key, value := range args.search.Fields {
+			snapshotSearch.Fields[key] = value
+		}
+		args.search = *snapshotSearch
+	}
+
+	// Cancel the context on SIGINT/SIGTERM so an in-flight download is
+	// interrupted cleanly, leaving .part files in place for a later resume
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if args.serve != "" {
+		serve(&args)
+	} else if args.displayDataNodes {
 		outputDataNodes(&args)
 	} else if args.valuesFor != "" {
 		outputValuesFor(&args)
 	} else if args.fieldKeys {
 		outputFields(&args)
 	} else if len(args.search.Fields) > 0 {
-		getBySearch(&args)
+		getBySearch(ctx, &args)
 	} else {
 		flag.Usage()
 	}
 }
+
+// serve runs sproket as an embedded web server until the process is killed,
+// so a group sharing a compute node can browse fields, facets and queue
+// downloads without each maintaining their own JSON config.
+func serve(args *config) {
+	srv := web.NewServer(&args.search, args.outDir, args.parallel)
+	fmt.Printf("serving on %s\n", args.serve)
+	if err := http.ListenAndServe(args.serve, srv.Handler()); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// progressBar is a minimal, dependency-free aggregate progress display. Any
+// number of download workers call Add concurrently; a single ticker goroutine
+// renders the running total on an interval until Stop is called.
+type progressBar struct {
+	bytes    int64
+	total    int64
+	stopChan chan struct{}
+	doneChan chan struct{}
+}
+
+// newProgressBar starts a progressBar that renders every interval. A total of
+// 0 means the expected size is unknown and only bytes transferred are shown.
+func newProgressBar(total int64, interval time.Duration) *progressBar {
+	p := &progressBar{
+		total:    total,
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+	go p.run(interval)
+	return p
+}
+
+// Add reports n additional bytes written by a worker. A nil progressBar
+// (the -progress flag was not set) is a no-op, so callers can pass it
+// unconditionally as a Search.Get progress callback.
+func (p *progressBar) Add(n int64) {
+	if p == nil {
+		return
+	}
+	atomic.AddInt64(&p.bytes, n)
+}
+
+func (p *progressBar) run(interval time.Duration) {
+	defer close(p.doneChan)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.render()
+		case <-p.stopChan:
+			p.render()
+			fmt.Println()
+			return
+		}
+	}
+}
+
+func (p *progressBar) render() {
+	done := atomic.LoadInt64(&p.bytes)
+	if p.total > 0 {
+		pct := float64(done) / float64(p.total) * 100
+		fmt.Printf("\r%s / %s (%.1f%%)    ", humanBytes(done), humanBytes(p.total), pct)
+	} else {
+		fmt.Printf("\r%s downloaded    ", humanBytes(done))
+	}
+}
+
+// Stop flushes a final render and blocks until the render goroutine exits.
+func (p *progressBar) Stop() {
+	close(p.stopChan)
+	<-p.doneChan
+}
+
+// humanBytes formats n bytes using the usual 1024-based suffixes.
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for next := n / unit; next >= unit; next /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
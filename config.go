@@ -9,4 +9,23 @@ type Search struct {
 	DataNodePriority []string          `json:"data_node_priority"`
 	Agent            string
 	HTTPClient       *http.Client
+
+	// Backoff controls the delay between retries of transient failures
+	// against Solr index nodes and data nodes. Defaults to an
+	// ExponentialBackoff if left nil.
+	Backoff Backoff
+	// MaxRetries caps how many times a request is retried after a
+	// transient failure. Defaults to 5 if left at zero.
+	MaxRetries int
+
+	// RetryInitialMs, RetryMaxMs and RetryMaxAttempts let a JSON config
+	// file tune the default Backoff/MaxRetries without constructing one
+	// in code; see config.Init in cmd/sproket/main.go.
+	RetryInitialMs   int `json:"retry_initial_ms"`
+	RetryMaxMs       int `json:"retry_max_ms"`
+	RetryMaxAttempts int `json:"retry_max_attempts"`
+
+	// Snapshot, if set, serves SearchURLs, Facet and GetFields from a local
+	// data source instead of querying API. See sproket/index.
+	Snapshot SnapshotSource
 }
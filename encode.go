@@ -0,0 +1,114 @@
+package sproket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DocEncoder writes one record per matching file to an underlying
+// io.Writer as a search pages through results, so sproket can stream
+// output (to stdout, a pipe, a catalog ingester) instead of buffering the
+// whole result set just to print URLs.
+type DocEncoder interface {
+	Encode(doc Doc) error
+}
+
+// URLEncoder writes just the resolved HTTPURL, one per line, matching the
+// output of -urls.only.
+type URLEncoder struct {
+	W io.Writer
+}
+
+// Encode implements DocEncoder.
+func (e *URLEncoder) Encode(doc Doc) error {
+	_, err := fmt.Fprintln(e.W, doc.HTTPURL)
+	return err
+}
+
+// jsonlRecord is a Doc plus the single checksum value/algorithm computed
+// from its (possibly multivalued) Sum/SumType fields, so consumers don't
+// have to special-case the multivalue arrays.
+type jsonlRecord struct {
+	Doc
+	ChecksumAlgorithm string `json:"checksum_algorithm,omitempty"`
+	ChecksumValue     string `json:"checksum_value,omitempty"`
+}
+
+// JSONLEncoder writes the full Doc, one JSON object per line, suitable for
+// piping into jq, xargs -P, GNU parallel, or ingestion into a catalog.
+type JSONLEncoder struct {
+	enc *json.Encoder
+}
+
+// NewJSONLEncoder returns a JSONLEncoder writing to w.
+func NewJSONLEncoder(w io.Writer) *JSONLEncoder {
+	return &JSONLEncoder{enc: json.NewEncoder(w)}
+}
+
+// Encode implements DocEncoder.
+func (e *JSONLEncoder) Encode(doc Doc) error {
+	return e.enc.Encode(jsonlRecord{
+		Doc:               doc,
+		ChecksumAlgorithm: doc.GetSumType(),
+		ChecksumValue:     doc.GetSum(),
+	})
+}
+
+// stacProperties lists the ESGF facet keys copied from a Search's query
+// criteria into every STAC Item's properties, when present.
+var stacProperties = []string{
+	"project", "experiment_id", "variable_id", "source_id", "table_id",
+	"frequency", "grid_label", "nominal_resolution",
+}
+
+// STACEncoder wraps each Doc as a minimal STAC Item, one JSON object per
+// line, with assets.data pointing at the resolved HTTPURL and carrying
+// checksum metadata.
+type STACEncoder struct {
+	enc        *json.Encoder
+	properties map[string]interface{}
+}
+
+// NewSTACEncoder returns a STACEncoder writing to w. fields is typically a
+// Search's Fields (the query criteria used to find these files); any key in
+// stacProperties it contains is copied into every Item's properties.
+func NewSTACEncoder(w io.Writer, fields map[string]string) *STACEncoder {
+	properties := map[string]interface{}{}
+	for _, key := range stacProperties {
+		if v, ok := fields[key]; ok {
+			properties[key] = v
+		}
+	}
+	return &STACEncoder{enc: json.NewEncoder(w), properties: properties}
+}
+
+// Encode implements DocEncoder.
+func (e *STACEncoder) Encode(doc Doc) error {
+	asset := map[string]interface{}{
+		"href":  doc.HTTPURL,
+		"roles": []string{"data"},
+	}
+	for i, sum := range doc.Sum {
+		if i >= len(doc.SumType) {
+			break
+		}
+		switch doc.SumType[i] {
+		case "MD5":
+			asset["checksum:md5"] = sum
+		case "SHA256":
+			asset["checksum:sha256"] = sum
+		}
+	}
+
+	item := map[string]interface{}{
+		"type":         "Feature",
+		"stac_version": "1.0.0",
+		"id":           doc.InstanceID,
+		"properties":   e.properties,
+		"assets": map[string]interface{}{
+			"data": asset,
+		},
+	}
+	return e.enc.Encode(item)
+}
@@ -15,6 +15,10 @@ type facetCounts struct {
 
 // Facet returns the values available for the provided field and the number of files that each value has
 func (s *Search) Facet(field string) map[string]int {
+	if s.Snapshot != nil {
+		return s.Snapshot.Facet(s.Fields, field)
+	}
+
 	q := s.buildQ()
 	params := map[string]string{
 		"query":  q,
@@ -15,6 +15,10 @@ type fieldResMid struct {
 
 // GetFields returns a slice of available fields for a search
 func (s *Search) GetFields() []string {
+	if s.Snapshot != nil {
+		return s.Snapshot.Fields()
+	}
+
 	q := s.buildQ()
 	params := map[string]string{
 		"query":  q,
@@ -0,0 +1,113 @@
+// Package index snapshots an ESGF Solr search result to a local,
+// append-only file so it can be searched and downloaded from again without
+// the live index node: a reproducible catalog that can be handed to an
+// air-gapped transfer node, or replayed months later even if the index has
+// drifted since.
+package index
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"sproket"
+)
+
+// header is the first line of an index file: enough context to know what
+// produced the snapshot and when.
+type header struct {
+	Query     string `json:"query"`
+	Timestamp string `json:"timestamp"`
+	IndexAPI  string `json:"index_api"`
+}
+
+// Build snapshots every File matching s into path as a line-delimited JSON
+// file: a header line followed by one full Solr document per matching file.
+// It pages through the entire result set via s.SearchDocsRaw, so very large
+// searches (e.g. a CMIP6 variable across every model) are written without
+// holding the whole result set in memory at once.
+func Build(s *sproket.Search, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	h := header{
+		Query:     s.Query(),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		IndexAPI:  s.API,
+	}
+	if err := writeLine(w, h); err != nil {
+		return err
+	}
+
+	limit := 250
+	for cur := 0; ; cur += limit {
+		docs, remaining := s.SearchDocsRaw(cur, limit)
+		for _, doc := range docs {
+			if err := writeLine(w, doc); err != nil {
+				return err
+			}
+		}
+		if remaining == 0 {
+			break
+		}
+	}
+	return w.Flush()
+}
+
+func writeLine(w *bufio.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+// Open reads an index file written by Build and returns a *sproket.Search
+// whose SearchURLs, Facet and GetFields are served from the snapshot
+// instead of querying a live index node, so it is a drop-in replacement for
+// a Search built from a JSON config anywhere downstream code expects one,
+// including the download engine in cmd/sproket.
+func Open(path string) (*sproket.Search, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var h header
+	if scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+			return nil, fmt.Errorf("%s: invalid index header: %w", path, err)
+		}
+	}
+
+	snap := newSnapshot()
+	for scanner.Scan() {
+		var raw map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+			return nil, fmt.Errorf("%s: invalid index record: %w", path, err)
+		}
+		snap.add(raw)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &sproket.Search{
+		API:      h.IndexAPI,
+		Fields:   map[string]string{},
+		Snapshot: snap,
+	}, nil
+}
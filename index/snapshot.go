@@ -0,0 +1,239 @@
+package index
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"sproket"
+)
+
+// snapshot implements sproket.SnapshotSource over documents loaded from an
+// index file. Every field is indexed at load time into both an unfiltered
+// facets count and a postings list (field -> value -> sorted doc indices), so
+// a filtered SearchDocs/Facet call — the common case, since cmd/sproket
+// always sends at least "replica" — resolves via set intersection over the
+// matching postings lists instead of an O(n) scan of every doc, the same way
+// a real inverted index would.
+type snapshot struct {
+	docs     []sproket.Doc
+	values   []map[string][]string // per-doc field -> values, for filtering
+	fields   []string
+	seen     map[string]bool
+	facets   map[string]map[string]int   // field -> value -> count, unfiltered
+	postings map[string]map[string][]int // field -> value -> sorted doc indices
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{
+		seen:     make(map[string]bool),
+		facets:   make(map[string]map[string]int),
+		postings: make(map[string]map[string][]int),
+	}
+}
+
+func (s *snapshot) add(raw map[string]interface{}) {
+	i := len(s.docs)
+	s.docs = append(s.docs, toDoc(raw))
+
+	docValues := make(map[string][]string, len(raw))
+	for key, value := range raw {
+		if !s.seen[key] {
+			s.seen[key] = true
+			s.fields = append(s.fields, key)
+		}
+		values := facetValues(value)
+		docValues[key] = values
+
+		bucket, ok := s.facets[key]
+		if !ok {
+			bucket = make(map[string]int)
+			s.facets[key] = bucket
+		}
+		postingBucket, ok := s.postings[key]
+		if !ok {
+			postingBucket = make(map[string][]int)
+			s.postings[key] = postingBucket
+		}
+		for _, v := range values {
+			bucket[v]++
+			postingBucket[v] = append(postingBucket[v], i)
+		}
+	}
+	s.values = append(s.values, docValues)
+}
+
+// matchingIndices resolves fields to the sorted doc indices satisfying it,
+// the same query criteria a live Search.Fields would be sent to Solr as:
+// every key's value must intersect the doc's indexed values for that key,
+// except "*" and "" which match any doc (including one missing the field
+// entirely), the same wildcard cmd/sproket uses for replica/data_node. A
+// value may itself list multiple acceptable values joined with " OR ",
+// mirroring buildQ. Rather than scanning every doc, each non-wildcard field
+// is resolved directly against its postings list and the per-field index
+// sets are intersected, so a selective field (e.g. "replica":"false") only
+// costs work proportional to the docs it actually matches.
+func (s *snapshot) matchingIndices(fields map[string]string) []int {
+	var sets []map[int]bool
+	for key, want := range fields {
+		if want == "" || want == "*" {
+			continue
+		}
+		set := make(map[int]bool)
+		for _, v := range strings.Split(want, " OR ") {
+			for _, i := range s.postings[key][v] {
+				set[i] = true
+			}
+		}
+		sets = append(sets, set)
+	}
+	if len(sets) == 0 {
+		indices := make([]int, len(s.docs))
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices
+	}
+
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+	result := sets[0]
+	for _, set := range sets[1:] {
+		next := make(map[int]bool, len(result))
+		for i := range result {
+			if set[i] {
+				next[i] = true
+			}
+		}
+		result = next
+	}
+
+	indices := make([]int, 0, len(result))
+	for i := range result {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// facetValues normalizes a raw JSON field value (a string, a bool, a number,
+// a multivalued array of any of those, or anything else) into the set of
+// string values to index. Solr flags like "replica" and "latest" come back
+// as JSON booleans, and must stringify to "true"/"false" to match the string
+// values cmd/sproket and sproket/web already filter by (e.g.
+// Fields["replica"] = "false").
+func facetValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			out = append(out, facetValues(item)...)
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// toDoc extracts the fields sproket.Doc cares about from a raw Solr
+// document, including the HTTPURL that Build resolved at snapshot time.
+func toDoc(raw map[string]interface{}) sproket.Doc {
+	var doc sproket.Doc
+	if v, ok := raw["instance_id"].(string); ok {
+		doc.InstanceID = v
+	}
+	if v, ok := raw["data_node"].(string); ok {
+		doc.DataNode = v
+	}
+	if v, ok := raw["HTTPURL"].(string); ok {
+		doc.HTTPURL = v
+	}
+	if v, ok := raw["size"].(float64); ok {
+		doc.Size = int64(v)
+	}
+	if urls, ok := raw["url"].([]interface{}); ok {
+		for _, u := range urls {
+			if s, ok := u.(string); ok {
+				doc.URLs = append(doc.URLs, s)
+			}
+		}
+	}
+	if sums, ok := raw["checksum"].([]interface{}); ok {
+		for _, c := range sums {
+			if s, ok := c.(string); ok {
+				doc.Sum = append(doc.Sum, s)
+			}
+		}
+	}
+	if types, ok := raw["checksum_type"].([]interface{}); ok {
+		for _, t := range types {
+			if s, ok := t.(string); ok {
+				doc.SumType = append(doc.SumType, s)
+			}
+		}
+	}
+	return doc
+}
+
+// SearchDocs implements sproket.SnapshotSource.
+func (s *snapshot) SearchDocs(fields map[string]string, skip int, limit int) ([]sproket.Doc, int) {
+	matching := s.matchingDocs(fields)
+
+	total := len(matching)
+	if skip > total {
+		skip = total
+	}
+	end := skip
+	if limit > 0 {
+		end = skip + limit
+		if end > total {
+			end = total
+		}
+	}
+	remaining := total - end
+	if remaining < 0 {
+		remaining = 0
+	}
+	return matching[skip:end], remaining
+}
+
+// matchingDocs returns the Docs satisfying fields, or every Doc if fields is
+// empty (the common, unfiltered case an index.Open snapshot starts from).
+func (s *snapshot) matchingDocs(fields map[string]string) []sproket.Doc {
+	if len(fields) == 0 {
+		return s.docs
+	}
+	indices := s.matchingIndices(fields)
+	matching := make([]sproket.Doc, len(indices))
+	for j, i := range indices {
+		matching[j] = s.docs[i]
+	}
+	return matching
+}
+
+// Facet implements sproket.SnapshotSource. With no fields to filter by, it
+// returns the inverted index built at load time; otherwise it recounts field
+// across the postings-resolved docs matching fields, the same way a live
+// Facet query would recount against whatever criteria narrowed the search.
+func (s *snapshot) Facet(fields map[string]string, field string) map[string]int {
+	if len(fields) == 0 {
+		return s.facets[field]
+	}
+	counts := make(map[string]int)
+	for _, i := range s.matchingIndices(fields) {
+		for _, v := range s.values[i][field] {
+			counts[v]++
+		}
+	}
+	return counts
+}
+
+// Fields implements sproket.SnapshotSource.
+func (s *snapshot) Fields() []string {
+	return s.fields
+}
@@ -1,47 +1,303 @@
 package sproket
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 )
 
-// Get sets the User-Agent header, performs the GET and writes to the specified dest io writer
-func (s *Search) Get(inURL string, dest io.Writer) error {
+// ErrRestartRequired is returned by Get when a resumed download could not be
+// honored by the remote server (the resource changed since the partial file
+// was written, or the server does not support range requests at all). The
+// caller is expected to truncate its destination and retry the download from
+// the beginning.
+var ErrRestartRequired = errors.New("sproket: remote resource changed or range requests unsupported, restart required")
 
-	// Setup http client and set the User-Agent header
-	req, err := http.NewRequest("GET", inURL, nil)
+// retryableError marks an error as a transient failure worth retrying, and
+// optionally carries a server-requested Retry-After delay.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (r *retryableError) Error() string { return r.err.Error() }
+func (r *retryableError) Unwrap() error { return r.err }
+
+func isRetryable(err error) bool {
+	var r *retryableError
+	return errors.As(err, &r)
+}
+
+// Get sets the User-Agent header, performs the GET and writes to the specified
+// dest io.Writer, retrying transient failures (network errors, HTTP 5xx, 429,
+// 408 and short-read "response size mismatch" errors) using s.Backoff up to
+// s.MaxRetries times and honoring any Retry-After header. If resumeFrom is
+// greater than zero, a "Range: bytes=<resumeFrom>-" request is issued along
+// with an "If-Range" validator so the transfer only resumes if the remote
+// file has not changed since it was last fetched; dest is assumed to already
+// hold resumeFrom bytes (e.g. an os.File opened with O_APPEND). If progress is
+// non-nil it is called with the number of bytes written for every chunk
+// copied, so callers can aggregate download speed across concurrent workers.
+// ctx allows an in-flight download (including the retry backoff sleeps) to be
+// cancelled, e.g. in response to an interrupt signal.
+//
+// validator lets a caller persist the If-Range value across process
+// restarts: if resumeFrom is greater than zero and validator is non-empty,
+// it is used as-is instead of issuing a fresh HEAD request, so a resume
+// validates against the ETag/Last-Modified in effect when the .part file was
+// written rather than whatever the remote happens to be serving now. If
+// resumeFrom is zero, onValidator (if non-nil) is called with the
+// ETag/Last-Modified of the response as soon as headers arrive — before any
+// bytes are written, and regardless of whether the transfer that follows
+// ultimately succeeds — so the caller can persist it immediately rather than
+// only once Get returns successfully. A validator obtained this way is also
+// reused for any further retries within this same Get call.
+func (s *Search) Get(ctx context.Context, inURL string, dest io.Writer, resumeFrom int64, progress func(n int64), validator string, onValidator func(v string)) error {
+
+	backoff := s.backoff()
+	backoff.Reset()
+	maxRetries := s.maxRetries()
+
+	cur := resumeFrom
+	for attempt := 0; ; attempt++ {
+		err := s.getOnce(ctx, inURL, dest, &cur, progress, validator, func(v string) {
+			validator = v
+			if onValidator != nil {
+				onValidator(v)
+			}
+		})
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrRestartRequired) || ctx.Err() != nil || !isRetryable(err) || attempt >= maxRetries {
+			return err
+		}
+
+		wait := backoff.Next(attempt + 1)
+		var retryErr *retryableError
+		if errors.As(err, &retryErr) && retryErr.retryAfter > 0 {
+			wait = retryErr.retryAfter
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// getOnce performs a single GET attempt starting at *resumeFrom, advancing
+// *resumeFrom as bytes are written so a subsequent retry resumes exactly
+// where this attempt left off.
+func (s *Search) getOnce(ctx context.Context, inURL string, dest io.Writer, resumeFrom *int64, progress func(n int64), validator string, onValidator func(string)) error {
+
+	req, err := http.NewRequestWithContext(ctx, "GET", inURL, nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("User-Agent", s.Agent)
 
+	if *resumeFrom > 0 {
+		if validator != "" {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", *resumeFrom))
+			req.Header.Set("If-Range", validator)
+		} else if v, err := s.rangeValidator(ctx, inURL); err == nil && v != "" {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", *resumeFrom))
+			req.Header.Set("If-Range", v)
+		}
+	}
+
 	// Perform the HTTP request
 	resp, err := s.HTTPClient.Do(req)
 	if err != nil {
-		return err
+		if ctx.Err() != nil {
+			return err
+		}
+		return &retryableError{err: err}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server ignored our Range/If-Range request (no range support, or
+		// the resource changed), so it sent the full body from byte 0. Any
+		// bytes already written to dest from a previous attempt no longer
+		// line up with this response.
+		if *resumeFrom > 0 {
+			return ErrRestartRequired
+		}
+		// Fresh download: capture the validator in effect right now, before
+		// any bytes are written, so a later resume can check against this
+		// value instead of whatever the remote is serving at resume time.
+		if onValidator != nil {
+			if etag := resp.Header.Get("ETag"); etag != "" {
+				onValidator(etag)
+			} else if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+				onValidator(lastModified)
+			}
+		}
+	case http.StatusPartialContent:
+		// Resuming as requested
+	default:
+		if isRetryableStatus(resp.StatusCode) {
+			return &retryableError{err: errors.New(resp.Status), retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		}
 		return errors.New(resp.Status)
 	}
 
-	// Grab the expected size
+	// Grab the expected size of the body we're about to read
 	expectedSize, err := strconv.ParseInt(resp.Header.Get("content-length"), 10, 64)
 	if err != nil {
 		expectedSize = int64(-1)
 	}
 
+	writer := io.Writer(dest)
+	writer = &progressWriter{w: writer, onWrite: func(n int64) {
+		*resumeFrom += n
+		if progress != nil {
+			progress(n)
+		}
+	}}
+
 	// Write to destination
-	nBytes, err := io.Copy(dest, resp.Body)
+	nBytes, err := io.Copy(writer, resp.Body)
 	if err != nil {
-		return err
+		if ctx.Err() != nil {
+			return err
+		}
+		return &retryableError{err: err}
 	}
 	if expectedSize != -1 && nBytes != expectedSize {
-		return fmt.Errorf("response size mismatch: %d != %d", nBytes, expectedSize)
+		return &retryableError{err: fmt.Errorf("response size mismatch: %d != %d", nBytes, expectedSize)}
 	}
 	return nil
 }
+
+// getBody performs a GET against inURL and returns the whole response body,
+// retrying transient failures the same way Get does. Unlike Get, it has no
+// notion of resuming a partial transfer: every attempt re-issues the request
+// from scratch and starts from an empty body, which is correct for a
+// one-shot Solr query response (what performSearch uses this for) but would
+// be wrong for a file whose destination can't simply be thrown away and
+// restarted (see Get, which a caller writing to a resumable destination like
+// an *os.File should use instead).
+func (s *Search) getBody(ctx context.Context, inURL string) ([]byte, error) {
+	backoff := s.backoff()
+	backoff.Reset()
+	maxRetries := s.maxRetries()
+
+	for attempt := 0; ; attempt++ {
+		body, err := s.getBodyOnce(ctx, inURL)
+		if err == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil || !isRetryable(err) || attempt >= maxRetries {
+			return nil, err
+		}
+
+		wait := backoff.Next(attempt + 1)
+		var retryErr *retryableError
+		if errors.As(err, &retryErr) && retryErr.retryAfter > 0 {
+			wait = retryErr.retryAfter
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *Search) getBodyOnce(ctx context.Context, inURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", inURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.Agent)
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &retryableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if isRetryableStatus(resp.StatusCode) {
+			return nil, &retryableError{err: errors.New(resp.Status), retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		}
+		return nil, errors.New(resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &retryableError{err: err}
+	}
+	return body, nil
+}
+
+// parseRetryAfter interprets a Retry-After header given as a number of
+// seconds. Retry-After may also be expressed as an HTTP-date, but ESGF nodes
+// are not known to do so, so that form is left to the default backoff.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+// rangeValidator issues a HEAD request for inURL and returns an ETag or,
+// failing that, a Last-Modified value suitable for use as an If-Range header.
+// An empty string with a nil error means the server gave us nothing to
+// validate against, so the caller should not attempt a range request.
+func (s *Search) rangeValidator(ctx context.Context, inURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, inURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", s.Agent)
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HEAD request failed: %s", resp.Status)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag, nil
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		return lastModified, nil
+	}
+	return "", nil
+}
+
+// progressWriter wraps an io.Writer and reports every successful write to
+// onWrite, letting a caller aggregate bytes written across many of these.
+type progressWriter struct {
+	w       io.Writer
+	onWrite func(n int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	if n > 0 {
+		p.onWrite(int64(n))
+	}
+	return n, err
+}
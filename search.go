@@ -1,7 +1,7 @@
 package sproket
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -26,6 +26,7 @@ type Doc struct {
 	DataNode   string   `json:"data_node"`
 	Sum        []string `json:"checksum"`
 	SumType    []string `json:"checksum_type"`
+	Size       int64    `json:"size"`
 	HTTPURL    string
 }
 
@@ -47,12 +48,16 @@ func (d *Doc) GetSumType() string {
 
 // SearchURLs returns a slice of up to "limit" download URLs
 func (s *Search) SearchURLs(skip int, limit int) ([]Doc, int) {
+	if s.Snapshot != nil {
+		return s.Snapshot.SearchDocs(s.Fields, skip, limit)
+	}
+
 	q := s.buildQ()
 	params := map[string]string{
 		"query":  q,
 		"type":   "File",
 		"format": "application/solr+json",
-		"fields": "instance_id,url,checksum,data_node,checksum_type",
+		"fields": "instance_id,url,checksum,data_node,checksum_type,size",
 		"limit":  fmt.Sprintf("%d", limit),
 		"offset": fmt.Sprintf("%d", skip),
 	}
@@ -85,6 +90,66 @@ func (s *Search) SearchURLs(skip int, limit int) ([]Doc, int) {
 	return docs, remaining
 }
 
+// rawRes stores the "response" portion of a Solr query result requested
+// with fields=* (full, untyped documents), for callers like sproket/index
+// that need more than SearchURLs' fixed field set.
+type rawRes struct {
+	Res rawResBody `json:"response"`
+}
+
+type rawResBody struct {
+	N    int                      `json:"numFound"`
+	Docs []map[string]interface{} `json:"docs"`
+}
+
+// SearchDocsRaw returns up to "limit" full Solr documents (every field, not
+// just the ones SearchURLs fixes), with an "HTTPURL" key resolved the same
+// way SearchURLs resolves Doc.HTTPURL, along with the number of further
+// matching documents remaining.
+func (s *Search) SearchDocsRaw(skip int, limit int) ([]map[string]interface{}, int) {
+	q := s.buildQ()
+	params := map[string]string{
+		"query":  q,
+		"type":   "File",
+		"format": "application/solr+json",
+		"fields": "*",
+		"limit":  fmt.Sprintf("%d", limit),
+		"offset": fmt.Sprintf("%d", skip),
+	}
+
+	body, err := s.performSearch(params)
+	if err != nil {
+		fmt.Println(err)
+		return nil, 0
+	}
+
+	var result rawRes
+	json.Unmarshal(body, &result)
+
+	docs := result.Res.Docs
+	for _, doc := range docs {
+		if urls, ok := doc["url"].([]interface{}); ok {
+			for _, u := range urls {
+				if s, ok := u.(string); ok && strings.Contains(s, "HTTPServer") {
+					doc["HTTPURL"] = strings.Split(s, "|")[0]
+				}
+			}
+		}
+	}
+
+	remaining := result.Res.N - (len(docs) + skip)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return docs, remaining
+}
+
+// Query returns the Solr query string built from s.Fields, the same one
+// SearchURLs, Facet and GetFields send to the index node.
+func (s *Search) Query() string {
+	return s.buildQ()
+}
+
 func (s *Search) performSearch(params map[string]string) ([]byte, error) {
 
 	// Build the search path
@@ -95,10 +160,11 @@ func (s *Search) performSearch(params map[string]string) ([]byte, error) {
 	query := values.Encode()
 	path := fmt.Sprintf("%s?%s", s.API, query)
 
-	// Perform query
-	buff := bytes.Buffer{}
-	err := s.Get(path, &buff)
-	return buff.Bytes(), err
+	// Perform query. Solr responses aren't resumable the way a downloaded
+	// file is, so this uses getBody rather than Get: a retried attempt needs
+	// a fresh, empty body to write into, not one already holding a partial
+	// response from the attempt that just failed.
+	return s.getBody(context.Background(), path)
 }
 
 func (s *Search) buildQ() string {
@@ -0,0 +1,23 @@
+package sproket
+
+// SnapshotSource lets a Search serve SearchURLs, Facet and GetFields from a
+// local, already-fetched data source (see sproket/index) instead of
+// querying the live Solr API at s.API. Setting Search.Snapshot turns a
+// Search into a drop-in, offline replacement for one built from a JSON
+// config, usable anywhere a live Search is: download workers, facet
+// browsing, field discovery.
+type SnapshotSource interface {
+	// SearchDocs returns up to "limit" Docs matching fields, starting at
+	// "skip", along with the number of further matching Docs remaining,
+	// mirroring SearchURLs. fields is a Search's Fields: each key's value is
+	// matched against the values indexed for that key, "*" or "" matching
+	// anything, so callers (e.g. the replica=false/true toggles getBySearch
+	// relies on) filter the snapshot the same way they'd filter a live
+	// search.
+	SearchDocs(fields map[string]string, skip int, limit int) ([]Doc, int)
+	// Facet returns the values available for field and how many Docs
+	// matching fields carry each value, mirroring Facet.
+	Facet(fields map[string]string, field string) map[string]int
+	// Fields returns the set of field names present in the snapshot.
+	Fields() []string
+}
@@ -0,0 +1,39 @@
+package web
+
+import "sync/atomic"
+
+// job tracks the progress of one in-flight /download request.
+type job struct {
+	id       string
+	total    int
+	bytes    int64
+	verified int64
+	failed   int64
+	done     int32
+}
+
+func (j *job) addBytes(n int64) { atomic.AddInt64(&j.bytes, n) }
+func (j *job) addVerified()     { atomic.AddInt64(&j.verified, 1) }
+func (j *job) addFailed()       { atomic.AddInt64(&j.failed, 1) }
+func (j *job) markDone()        { atomic.StoreInt32(&j.done, 1) }
+
+// jobStatus is the JSON shape streamed to GET /jobs/{id} as SSE data.
+type jobStatus struct {
+	ID       string `json:"id"`
+	Total    int    `json:"total"`
+	Bytes    int64  `json:"bytes"`
+	Verified int64  `json:"verified"`
+	Failed   int64  `json:"failed"`
+	Done     bool   `json:"done"`
+}
+
+func (j *job) snapshot() jobStatus {
+	return jobStatus{
+		ID:       j.id,
+		Total:    j.total,
+		Bytes:    atomic.LoadInt64(&j.bytes),
+		Verified: atomic.LoadInt64(&j.verified),
+		Failed:   atomic.LoadInt64(&j.failed),
+		Done:     atomic.LoadInt32(&j.done) != 0,
+	}
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+)
+
+// humanBytes formats n bytes using the usual 1024-based suffixes, so sizes
+// and byte counts render nicely instead of as raw integers.
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for next := n / unit; next >= unit; next /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+var funcs = template.FuncMap{
+	"humanBytes": humanBytes,
+}
+
+var indexTmpl = template.Must(template.New("index").Funcs(funcs).Parse(indexHTML))
+var searchTmpl = template.Must(template.New("search").Funcs(funcs).Parse(searchHTML))
+var facetTmpl = template.Must(template.New("facet").Funcs(funcs).Parse(facetHTML))
+
+const indexHTML = `<!doctype html>
+<html>
+<head><title>sproket</title></head>
+<body>
+<h1>sproket</h1>
+<form action="/search" method="get">
+<table>
+{{range .Fields}}<tr><td>{{.}}</td><td><input type="text" name="{{.}}"></td></tr>
+{{end}}
+</table>
+<button type="submit">Search</button>
+</form>
+</body>
+</html>
+`
+
+const searchHTML = `<!doctype html>
+<html>
+<head><title>sproket search</title></head>
+<body>
+<p>{{len .Docs}} shown, {{.Remaining}} more matching files</p>
+<table>
+<tr><th>instance_id</th><th>data_node</th><th>size</th><th>url</th></tr>
+{{range .Docs}}<tr><td>{{.InstanceID}}</td><td>{{.DataNode}}</td><td>{{humanBytes .Size}}</td><td><a href="{{.HTTPURL}}">{{.HTTPURL}}</a></td></tr>
+{{end}}
+</table>
+<form action="/download" method="post">
+{{range $key, $vals := .Criteria}}{{range $vals}}<input type="hidden" name="{{$key}}" value="{{.}}">
+{{end}}{{end}}<button type="submit">Download all matching files</button>
+</form>
+</body>
+</html>
+`
+
+const facetHTML = `<!doctype html>
+<html>
+<head><title>sproket facet: {{.Field}}</title></head>
+<body>
+<h1>{{.Field}}</h1>
+<table>
+<tr><th>value</th><th>count</th></tr>
+{{range .Values}}<tr><td>{{.}}</td><td>{{index $.Counts .}}</td></tr>
+{{end}}
+</table>
+</body>
+</html>
+`
@@ -0,0 +1,313 @@
+// Package web exposes a sproket.Search and its download engine over
+// HTTP+HTML: a form for picking fields and values, search results as HTML or
+// JSON, facet counts, and a queue of downloads served by a small worker
+// pool. This turns sproket from a one-shot CLI into something a small group
+// can share on a compute node without each person maintaining their own
+// JSON config.
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sproket"
+)
+
+// Server serves search, facet and download endpoints backed by search.
+type Server struct {
+	search   *sproket.Search
+	outDir   string
+	parallel int
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	nextID int64
+}
+
+// NewServer returns a Server that searches with search and writes downloads
+// into outDir using up to parallel concurrent workers per job.
+func NewServer(search *sproket.Search, outDir string, parallel int) *Server {
+	return &Server{
+		search:   search,
+		outDir:   outDir,
+		parallel: parallel,
+		jobs:     make(map[string]*job),
+	}
+}
+
+// Handler returns the http.Handler serving all of sproket/web's endpoints.
+func (srv *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", srv.handleIndex)
+	mux.HandleFunc("/search", srv.handleSearch)
+	mux.HandleFunc("/facets/", srv.handleFacet)
+	mux.HandleFunc("/download", srv.handleDownload)
+	mux.HandleFunc("/jobs/", srv.handleJob)
+	return mux
+}
+
+// searchFor builds a per-request Search that overlays query/form values onto
+// srv.search's base criteria, so the shared server can be pointed at one
+// base query (e.g. a project) while users refine it further.
+func (srv *Server) searchFor(values map[string][]string) sproket.Search {
+	s := *srv.search
+	fields := make(map[string]string, len(srv.search.Fields))
+	for k, v := range srv.search.Fields {
+		fields[k] = v
+	}
+	for key, vals := range searchCriteria(values) {
+		fields[key] = strings.Join(vals, " OR ")
+	}
+	s.Fields = fields
+	return s
+}
+
+func (srv *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	fields := srv.search.GetFields()
+	sort.Strings(fields)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTmpl.Execute(w, struct{ Fields []string }{fields}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (srv *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	s := srv.searchFor(r.URL.Query())
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			limit = n
+		}
+	}
+	skip := 0
+	if v := r.URL.Query().Get("skip"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			skip = n
+		}
+	}
+
+	docs, remaining := s.SearchURLs(skip, limit)
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"docs":      docs,
+			"remaining": remaining,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := struct {
+		Docs      []sproket.Doc
+		Remaining int
+		Criteria  map[string][]string
+	}{docs, remaining, searchCriteria(r.URL.Query())}
+	if err := searchTmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// searchCriteria strips the pagination/format parameters out of a query so
+// the remainder (the fields the user actually searched with) can be carried
+// into the download form as hidden inputs.
+func searchCriteria(values map[string][]string) map[string][]string {
+	criteria := make(map[string][]string, len(values))
+	for key, vals := range values {
+		switch key {
+		case "format", "limit", "skip":
+			continue
+		}
+		if len(vals) == 0 || vals[0] == "" {
+			continue
+		}
+		criteria[key] = vals
+	}
+	return criteria
+}
+
+func (srv *Server) handleFacet(w http.ResponseWriter, r *http.Request) {
+	field := strings.TrimPrefix(r.URL.Path, "/facets/")
+	if field == "" {
+		http.NotFound(w, r)
+		return
+	}
+	s := srv.searchFor(r.URL.Query())
+	counts := s.Facet(field)
+
+	if !wantsJSON(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		values := make([]string, 0, len(counts))
+		for v := range counts {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		facetTmpl.Execute(w, struct {
+			Field  string
+			Values []string
+			Counts map[string]int
+		}{field, values, counts})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counts)
+}
+
+func (srv *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s := srv.searchFor(r.Form)
+	// Constrain the queued download to originals: downloadOne writes each doc
+	// to a path keyed on InstanceID alone, so queuing a replica alongside its
+	// original (or two replicas of the same instance_id from different data
+	// nodes) means multiple workers would write the same destination file
+	// concurrently, corrupting it and inflating the job's file count. Unlike
+	// cmd/sproket's download path, sproket/web has no DataNodePriority list to
+	// fall back on a replica with, so originals-only is the right constraint
+	// here rather than a soft preference.
+	s.Fields["replica"] = "false"
+	_, n := s.SearchURLs(0, 0)
+
+	id := strconv.FormatInt(atomic.AddInt64(&srv.nextID, 1), 10)
+	j := &job{id: id, total: n}
+
+	srv.mu.Lock()
+	srv.jobs[id] = j
+	srv.mu.Unlock()
+
+	go srv.runDownload(context.Background(), j, s)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// runDownload pages through s's matching Docs and feeds them to srv.parallel
+// workers, mirroring the worker-pool pattern cmd/sproket's CLI download path
+// uses, tracking progress on j as it goes.
+func (srv *Server) runDownload(ctx context.Context, j *job, s sproket.Search) {
+	docChan := make(chan sproket.Doc)
+	waiter := sync.WaitGroup{}
+	workers := srv.parallel
+	if workers <= 0 {
+		workers = 4
+	}
+	for i := 0; i < workers; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			for doc := range docChan {
+				srv.downloadOne(ctx, &s, j, doc)
+			}
+		}()
+	}
+
+	limit := 250
+	for cur := 0; ; cur += limit {
+		docs, remaining := s.SearchURLs(cur, limit)
+		for _, doc := range docs {
+			docChan <- doc
+		}
+		if remaining == 0 {
+			break
+		}
+	}
+	close(docChan)
+	waiter.Wait()
+	j.markDone()
+}
+
+func (srv *Server) downloadOne(ctx context.Context, s *sproket.Search, j *job, doc sproket.Doc) {
+	dest := filepath.Join(srv.outDir, doc.InstanceID)
+	f, err := os.Create(dest)
+	if err != nil {
+		j.addFailed()
+		return
+	}
+	defer f.Close()
+
+	if err := s.Get(ctx, doc.HTTPURL, f, 0, j.addBytes, "", nil); err != nil {
+		j.addFailed()
+		return
+	}
+	f.Close()
+
+	if doc.GetSum() == "" || doc.GetSumType() == "" {
+		j.addVerified()
+		return
+	}
+	ok, err := sproket.VerifyFile(dest, doc.GetSum(), doc.GetSumType())
+	if err != nil || !ok {
+		j.addFailed()
+		return
+	}
+	j.addVerified()
+}
+
+func (srv *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	srv.mu.Lock()
+	j, ok := srv.jobs[id]
+	srv.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		status := j.snapshot()
+		data, _ := json.Marshal(status)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+		if status.Done {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func wantsJSON(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "json"
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}